@@ -0,0 +1,65 @@
+"""Benchmark of the per-trade hot path: decoding aggTrade messages and encoding broadcasts.
+
+Compares the full json.loads decode with parse_agg_trade's field extraction (and orjson
+when it is installed), reporting time and peak memory per message.
+
+    python bench_trade_path.py
+    python bench_trade_path.py --count 500000
+"""
+import argparse
+import json
+import timeit
+import tracemalloc
+
+from btc_alert_dashboard_web import FastJson, orjson, parse_agg_trade
+
+SAMPLE = json.dumps({
+    'e': 'aggTrade', 'E': 1714521600123, 's': 'BTCUSDT', 'a': 26129, 'p': '64123.45', 'q': '0.012',
+    'f': 100, 'l': 105, 'T': 1714521600120, 'm': True
+}, separators=(',', ':'))
+
+BROADCAST = {'price': '64123.45'}
+
+def json_decode(message):
+    data = json.loads(message)
+    return float(data['p']), int(data['T']), float(data['q'])
+
+def orjson_decode(message):
+    data = orjson.loads(message)
+    return float(data['p']), int(data['T']), float(data['q'])
+
+def peak_bytes(fn, arg, calls=100):
+    """Most memory one call holds at once, the dict a full decode builds shows up here"""
+    tracemalloc.start()
+    peaks = []
+    for _ in range(calls):
+        tracemalloc.reset_peak()
+        before, _ = tracemalloc.get_traced_memory()
+        fn(arg)
+        peaks.append(tracemalloc.get_traced_memory()[1] - before)
+    tracemalloc.stop()
+    return min(peaks)
+
+def report(name, fn, arg, count):
+    seconds = timeit.timeit(lambda: fn(arg), number=count)
+    print(f"{name:<28} {seconds / count * 1e9:8.0f} ns/op {peak_bytes(fn, arg):8.0f} B peak")
+
+def main():
+    parser = argparse.ArgumentParser(description='Benchmark the trade decode and broadcast encode path')
+    parser.add_argument('--count', type=int, default=200000, help='calls per benchmark')
+    args = parser.parse_args()
+
+    assert parse_agg_trade(SAMPLE) == json_decode(SAMPLE)
+    print('decode aggTrade')
+    report('json.loads', json_decode, SAMPLE, args.count)
+    if orjson:
+        report('orjson.loads', orjson_decode, SAMPLE, args.count)
+    report('parse_agg_trade', parse_agg_trade, SAMPLE, args.count)
+
+    print('encode price_update')
+    report('json.dumps', lambda data: json.dumps(data, separators=(',', ':')), BROADCAST, args.count)
+    if orjson:
+        report('FastJson.dumps (orjson)', FastJson.dumps, BROADCAST, args.count)
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,177 @@
+"""Settings from a config file and the environment, under the command line flags.
+
+Every setting has one name, e.g. max_candles, and is looked up in order:
+
+    --max-candles 500                 a flag, when the setting has one
+    CRYPTIC_MAX_CANDLES=500           the environment
+    max_candles = 500                 the --config file (or $CRYPTIC_CONFIG)
+    the built-in default
+
+The file is TOML (.toml), YAML (.yaml/.yml, needs pyyaml) or JSON (.json).
+Tables nest with underscores, so these are the same setting:
+
+    mqtt_qos = 1
+
+    [mqtt]
+    qos = 1
+
+A key no setting reads is an error, so a typo doesn't go unnoticed.
+"""
+import argparse
+import json
+import os
+
+ENV_PREFIX = 'CRYPTIC_'
+TRUE = ('1', 'true', 'yes', 'on')
+FALSE = ('0', 'false', 'no', 'off', '')
+
+class ConfigError(ValueError):
+    """A config file that can't be read or a setting that doesn't fit"""
+
+def read_file(path):
+    ext = os.path.splitext(path)[1].lower()
+    try:
+        if ext == '.toml':
+            try:
+                import tomllib
+            except ImportError:  # Before Python 3.11
+                try:
+                    import tomli as tomllib
+                except ImportError:
+                    raise ConfigError('TOML config files need Python 3.11 or: pip install tomli')
+            with open(path, 'rb') as f:
+                return tomllib.load(f)
+        if ext in ('.yaml', '.yml'):
+            try:
+                import yaml
+            except ImportError:
+                raise ConfigError('YAML config files need: pip install pyyaml')
+            with open(path) as f:
+                return yaml.safe_load(f) or {}
+        if ext == '.json':
+            with open(path) as f:
+                return json.load(f)
+    except OSError as e:
+        raise ConfigError(f"can't read config file {path}: {e.strerror}")
+    except ConfigError:
+        raise
+    except Exception as e:  # Each parser has its own error class
+        raise ConfigError(f"{path}: {e}")
+    raise ConfigError(f"config file {path} must be .toml, .yaml, .yml or .json")
+
+def flatten(data, prefix=''):
+    """{'mqtt': {'qos': 1}} to {'mqtt_qos': 1}, with dashes in keys read as underscores"""
+    if not isinstance(data, dict):
+        raise ConfigError('a config file holds a table of settings')
+    flat = {}
+    for key, value in data.items():
+        name = prefix + str(key).replace('-', '_').lower()
+        if isinstance(value, dict):
+            flat.update(flatten(value, name + '_'))
+        else:
+            flat[name] = value
+    return flat
+
+def convert(name, value, kind):
+    """value, as read from a file (any type) or the environment (a string), as kind"""
+    if value is None or kind is None:
+        return value
+    try:
+        if kind is bool:
+            if isinstance(value, bool):
+                return value
+            if str(value).strip().lower() in TRUE:
+                return True
+            if str(value).strip().lower() in FALSE:
+                return False
+            raise ValueError(value)
+        if kind is list:
+            if isinstance(value, str):
+                return [item.strip() for item in value.split(',') if item.strip()]
+            if not isinstance(value, list):
+                raise ValueError(value)
+            return [str(item) for item in value]
+        if kind in (int, float) and isinstance(value, bool):
+            raise ValueError(value)
+        if kind is str and not isinstance(value, str):
+            raise ValueError(value)
+        return kind(value)
+    except (TypeError, ValueError, argparse.ArgumentTypeError) as e:
+        if kind not in (bool, list, int, float, str):  # A flag's own parser explains itself
+            raise ConfigError(f"{name}: {e}")
+        expected = {bool: 'true or false', list: 'a list', int: 'a whole number', float: 'a number', str: 'text'}[kind]
+        raise ConfigError(f"{name} must be {expected}, got {value!r}")
+
+def config_path(argv, environ=os.environ):
+    """The --config argument in argv, else $CRYPTIC_CONFIG"""
+    for i, arg in enumerate(argv):
+        if arg == '--config' and i + 1 < len(argv):
+            return argv[i + 1]
+        if arg.startswith('--config='):
+            return arg.split('=', 1)[1]
+    return environ.get(ENV_PREFIX + 'CONFIG')
+
+class Settings:
+    def __init__(self, path=None, environ=os.environ):
+        self.path = path
+        self.environ = environ
+        self.values = flatten(read_file(path)) if path else {}
+        self.read = set()  # Names looked up, whatever is left in the file is a typo
+
+    def source(self, name, env=None):
+        """(value, where it came from), or (None, None) when only the default applies"""
+        env = env or ENV_PREFIX + name.upper()
+        if env in self.environ:
+            return self.environ[env], f"${env}"
+        if name in self.values:
+            return self.values[name], f"{name} in {self.path}"
+        return None, None
+
+    def get(self, name, default=None, kind=str, env=None):
+        """Setting name as kind, env names the variable when it isn't CRYPTIC_<NAME>"""
+        self.read.add(name)
+        value, where = self.source(name, env)
+        if where is None:
+            return default
+        return convert(where, value, kind)
+
+    def apply(self, parser, skip=('help', 'config')):
+        """Make the environment and file the defaults of parser's flags, so flags still win"""
+        defaults = {}
+        for action in parser._actions:
+            if action.dest in skip or not action.option_strings:
+                continue
+            self.read.add(action.dest)
+            value, where = self.source(action.dest)
+            if where is None:
+                continue
+            if action.nargs == 0:  # store_true and friends
+                value = convert(where, value, bool)
+                if not value:
+                    continue
+                value = action.const
+            elif isinstance(action, argparse._AppendAction):
+                value = [self.flag_value(action, where, item) for item in convert(where, value, list)]
+            else:
+                value = self.flag_value(action, where, value)
+            defaults[action.dest] = value
+        parser.set_defaults(**defaults)
+
+    @staticmethod
+    def flag_value(action, where, value):
+        if action.type is not None and (isinstance(value, str) or action.type in (int, float)):
+            value = convert(where, value, action.type)
+        if action.choices is not None and value not in action.choices:
+            raise ConfigError(f"{where} must be one of {', '.join(map(str, action.choices))}, got {value!r}")
+        return value
+
+    def check(self):
+        """Raise ConfigError for file keys no setting reads"""
+        unknown = sorted(set(self.values) - self.read)
+        if unknown:
+            raise ConfigError(f"unknown setting{'s' if len(unknown) > 1 else ''} in {self.path}: "
+                              f"{', '.join(unknown)}")
+
+    def describe(self):
+        """Names set by the file, for the startup log, values left out as they may be secrets"""
+        return {'file': self.path, 'settings': sorted(self.values)}
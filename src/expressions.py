@@ -0,0 +1,316 @@
+"""Alert conditions written as expressions, evaluated each time a candle closes.
+
+    RSI(14, "1h") < 30 && close > EMA(200, "4h")
+    CHANGE(3) > 2 || (price < BB_LOWER(20, 2) and !(RSI(14) > 40))
+
+Numbers, "strings" (timeframes), parentheses, + - * /, comparisons (< <= > >= == !=)
+and the logic operators && || ! (or and, or, not) work as usual. The whole
+expression must come out true or false.
+
+Values:
+    open, high, low, close   the last closed candle of the alert's timeframe
+    price                    the last traded price
+    OPEN/HIGH/LOW/CLOSE(tf)  the last closed candle of another timeframe
+    RSI(period[, tf])  EMA(period[, tf])  SMA(period[, tf])
+    BB_UPPER(period, std[, tf])  BB_MIDDLE(period[, tf])  BB_LOWER(period, std[, tf])
+    CHANGE(candles[, tf])    percent change of the close over that many candles
+    ABS(x)  MIN(a, b)  MAX(a, b)
+
+A timeframe left out is the alert's own. Expressions are checked when they're
+parsed, so a typo or a number compared with true is refused up front rather
+than failing on every candle.
+"""
+import math
+import re
+
+import pandas as pd
+from ta.momentum import RSIIndicator
+from ta.trend import EMAIndicator, SMAIndicator
+from ta.volatility import BollingerBands
+
+MAX_LENGTH = 500  # Characters in one expression
+MAX_PERIOD = 500  # Longest indicator window
+
+TOKEN = re.compile(r'\s*(?:'
+                   r'(\d+(?:\.\d*)?|\.\d+)'  # Number
+                   r'|"([^"]*)"'  # String
+                   r'|([A-Za-z_][A-Za-z_0-9]*)'  # Name
+                   r'|(&&|\|\||<=|>=|==|!=|[-+*/<>!(),]))')  # Operator
+
+KEYWORDS = {'and': '&&', 'or': '||', 'not': '!'}
+CONSTANTS = {'true': True, 'false': False}
+CANDLE_FIELDS = ('open', 'high', 'low', 'close')
+
+# Binding power of the binary operators, higher binds tighter
+BINARY = {'||': 1, '&&': 2, '==': 3, '!=': 3, '<': 4, '<=': 4, '>': 4, '>=': 4, '+': 5, '-': 5, '*': 6, '/': 6}
+
+class ExpressionError(ValueError):
+    """The expression doesn't parse or doesn't make sense, the message says where"""
+
+class NotEnoughData(Exception):
+    """Not enough closed candles yet for one of the indicators"""
+
+def tokenize(source):
+    tokens, pos = [], 0
+    source = source.rstrip()
+    while pos < len(source):
+        match = TOKEN.match(source, pos)
+        if not match:
+            rest = source[pos:].lstrip()
+            raise ExpressionError(f"unexpected {rest[:10]!r} at {len(source) - len(rest)}")
+        number, text, name, op = match.groups()
+        if number is not None:
+            tokens.append(('num', float(number)))
+        elif text is not None:
+            tokens.append(('str', text))
+        elif name is not None:
+            lowered = name.lower()
+            if lowered in KEYWORDS:
+                tokens.append(('op', KEYWORDS[lowered]))
+            elif lowered in CONSTANTS:
+                tokens.append(('bool', CONSTANTS[lowered]))
+            else:
+                tokens.append(('name', name))
+        else:
+            tokens.append(('op', op))
+        pos = match.end()
+    return tokens
+
+class Parser:
+    """Precedence climbing over the tokens, producing nested tuples:
+    ('num', 1.0), ('str', '1h'), ('bool', True), ('var', 'close'), ('call', 'RSI', [args]),
+    ('not', x), ('neg', x) and (operator, left, right)
+    """
+    def __init__(self, tokens):
+        self.tokens = tokens
+        self.pos = 0
+
+    def peek(self):
+        return self.tokens[self.pos] if self.pos < len(self.tokens) else (None, None)
+
+    def take(self, value=None):
+        token = self.peek()
+        if token[0] is None or (value is not None and token[1] != value):
+            raise ExpressionError(f"expected {value or 'a value'} but found {token[1] if token[0] else 'the end'}")
+        self.pos += 1
+        return token
+
+    def parse(self):
+        node = self.binary(0)
+        if self.pos < len(self.tokens):
+            raise ExpressionError(f"unexpected {self.tokens[self.pos][1]!r} after the expression")
+        return node
+
+    def binary(self, min_power):
+        left = self.unary()
+        while True:
+            kind, op = self.peek()
+            power = BINARY.get(op) if kind == 'op' else None
+            if power is None or power <= min_power:
+                return left
+            self.pos += 1
+            left = (op, left, self.binary(power))
+
+    def unary(self):
+        kind, value = self.peek()
+        if kind == 'op' and value == '!':
+            self.pos += 1
+            return ('not', self.unary())
+        if kind == 'op' and value == '-':
+            self.pos += 1
+            return ('neg', self.unary())
+        return self.primary()
+
+    def primary(self):
+        kind, value = self.take()
+        if kind in ('num', 'str', 'bool'):
+            return (kind, value)
+        if kind == 'op' and value == '(':
+            node = self.binary(0)
+            self.take(')')
+            return node
+        if kind == 'name':
+            if self.peek() == ('op', '('):
+                self.pos += 1
+                args = []
+                if self.peek() != ('op', ')'):
+                    args.append(self.binary(0))
+                    while self.peek() == ('op', ','):
+                        self.pos += 1
+                        args.append(self.binary(0))
+                self.take(')')
+                return ('call', value.upper(), args)
+            return ('var', value.lower())
+        raise ExpressionError(f"unexpected {value!r}")
+
+# Function name -> (number of leading numeric arguments, whether a timeframe may follow)
+FUNCTIONS = {
+    'RSI': (1, True), 'EMA': (1, True), 'SMA': (1, True), 'CHANGE': (1, True),
+    'BB_UPPER': (2, True), 'BB_MIDDLE': (1, True), 'BB_LOWER': (2, True),
+    'OPEN': (0, True), 'HIGH': (0, True), 'LOW': (0, True), 'CLOSE': (0, True),
+    'ABS': (1, False), 'MIN': (2, False), 'MAX': (2, False)
+}
+MATH = {'ABS': abs, 'MIN': min, 'MAX': max}
+
+class Expression:
+    """A parsed expression, bound to the timeframe it's evaluated on"""
+    def __init__(self, source, timeframe, timeframes):
+        if not isinstance(source, str) or not source.strip():
+            raise ExpressionError('the expression is empty')
+        if len(source) > MAX_LENGTH:
+            raise ExpressionError(f"the expression is longer than {MAX_LENGTH} characters")
+        if timeframe not in timeframes:
+            raise ExpressionError(f"unknown timeframe {timeframe}, use one of {', '.join(timeframes)}")
+        self.source = source
+        self.timeframe = timeframe
+        self.valid_timeframes = timeframes
+        self.timeframes = {timeframe}  # Every timeframe the expression reads
+        self.tree = Parser(tokenize(source)).parse()
+        if self.check(self.tree) != 'bool':
+            raise ExpressionError('the expression must be a condition, true or false')
+
+    def check(self, node):
+        """Type of node, 'num', 'str' or 'bool', raising ExpressionError where types don't fit"""
+        kind = node[0]
+        if kind in ('num', 'str', 'bool'):
+            return kind
+        if kind == 'var':
+            if node[1] not in CANDLE_FIELDS + ('price',):
+                raise ExpressionError(f"unknown value {node[1]}, use {', '.join(CANDLE_FIELDS + ('price',))}")
+            return 'num'
+        if kind == 'call':
+            return self.check_call(node[1], node[2])
+        if kind == 'not':
+            self.expect(node[1], 'bool', '!')
+            return 'bool'
+        if kind == 'neg':
+            self.expect(node[1], 'num', '-')
+            return 'num'
+        op, left, right = node
+        if op in ('&&', '||'):
+            self.expect(left, 'bool', op)
+            self.expect(right, 'bool', op)
+            return 'bool'
+        if op in ('==', '!='):
+            if self.check(left) != self.check(right):
+                raise ExpressionError(f"{op} compares two values of different kinds")
+            return 'bool'
+        self.expect(left, 'num', op)
+        self.expect(right, 'num', op)
+        return 'bool' if op in ('<', '<=', '>', '>=') else 'num'
+
+    def expect(self, node, kind, op):
+        if self.check(node) != kind:
+            raise ExpressionError(f"{op} needs {'a number' if kind == 'num' else 'a condition'}")
+
+    def check_call(self, name, args):
+        if name not in FUNCTIONS:
+            raise ExpressionError(f"unknown function {name}, use {', '.join(FUNCTIONS)}")
+        numbers, timeframe = FUNCTIONS[name]
+        if not numbers <= len(args) <= numbers + timeframe:
+            raise ExpressionError(f"{name} takes {numbers} number{'s' if numbers != 1 else ''}"
+                                  f"{' and an optional timeframe' if timeframe else ''}")
+        for arg in args[:numbers]:
+            self.expect(arg, 'num', name)
+        if name in MATH:
+            return 'num'
+        # Indicator settings must be plain numbers, a window can't change from one candle to the next
+        for arg in args[:numbers]:
+            if arg[0] != 'num' or arg[1] <= 0 or arg[1] > MAX_PERIOD:
+                raise ExpressionError(f"{name} settings must be numbers between 0 and {MAX_PERIOD}")
+        # The first setting is a window of candles, which only comes in whole ones (std can be fractional)
+        if numbers and (args[0][1] < 1 or args[0][1] != int(args[0][1])):
+            raise ExpressionError(f"{name} period must be a whole number of candles, at least 1")
+        if len(args) > numbers:
+            if args[-1][0] != 'str' or args[-1][1] not in self.valid_timeframes:
+                raise ExpressionError(f"{name} timeframe must be one of "
+                                      f"{', '.join(repr(tf) for tf in self.valid_timeframes)}")
+            self.timeframes.add(args[-1][1])
+        return 'num'
+
+    def evaluate(self, frames, price):
+        """True or False given DataFrames of closed candles per timeframe (oldest first) and the last
+        price, None when some timeframe hasn't got enough candles yet"""
+        cache = {}
+        try:
+            return bool(self.value(self.tree, frames, price, cache))
+        except (NotEnoughData, ZeroDivisionError):
+            return None
+
+    def value(self, node, frames, price, cache):
+        kind = node[0]
+        if kind in ('num', 'str', 'bool'):
+            return node[1]
+        if kind == 'var':
+            if node[1] == 'price':
+                return price
+            return self.last(frames, self.timeframe, node[1])
+        if kind == 'not':
+            return not self.value(node[1], frames, price, cache)
+        if kind == 'neg':
+            return -self.value(node[1], frames, price, cache)
+        if kind == 'call':
+            return self.call(node[1], node[2], frames, price, cache)
+        op, left, right = node
+        a = self.value(left, frames, price, cache)
+        if op == '&&':
+            return a and self.value(right, frames, price, cache)
+        if op == '||':
+            return a or self.value(right, frames, price, cache)
+        b = self.value(right, frames, price, cache)
+        return {
+            '==': lambda: a == b, '!=': lambda: a != b, '<': lambda: a < b, '<=': lambda: a <= b,
+            '>': lambda: a > b, '>=': lambda: a >= b, '+': lambda: a + b, '-': lambda: a - b,
+            '*': lambda: a * b, '/': lambda: a / b
+        }[op]()
+
+    @staticmethod
+    def last(frames, tf, column):
+        df = frames.get(tf)
+        if df is None or df.empty:
+            raise NotEnoughData(tf)
+        return float(df[column].iloc[-1])
+
+    def call(self, name, args, frames, price, cache):
+        if name in MATH:
+            return MATH[name](*(self.value(arg, frames, price, cache) for arg in args))
+        numbers = FUNCTIONS[name][0]
+        settings = [arg[1] for arg in args[:numbers]]
+        tf = args[-1][1] if len(args) > numbers else self.timeframe
+        if name in ('OPEN', 'HIGH', 'LOW', 'CLOSE'):
+            return self.last(frames, tf, name.lower())
+        key = (name, tuple(settings), tf)
+        if key not in cache:
+            cache[key] = indicator(name, settings, frames.get(tf))
+        return cache[key]
+
+def indicator(name, settings, df):
+    """Latest value of an indicator over the closes in df"""
+    window = int(settings[0])
+    needed = window + 1 if name in ('RSI', 'CHANGE') else window
+    if df is None or len(df) < needed:
+        raise NotEnoughData(name)
+    close = df['close'].astype(float)
+    if name == 'RSI':
+        series = RSIIndicator(close, window=window).rsi()
+    elif name == 'EMA':
+        series = EMAIndicator(close, window=window).ema_indicator()
+    elif name == 'SMA':
+        series = SMAIndicator(close, window=window).sma_indicator()
+    elif name == 'CHANGE':
+        series = close.pct_change(window) * 100
+    else:
+        bb = BollingerBands(close, window=window, window_dev=settings[1] if len(settings) > 1 else 2)
+        series = {'BB_UPPER': bb.bollinger_hband, 'BB_MIDDLE': bb.bollinger_mavg,
+                  'BB_LOWER': bb.bollinger_lband}[name]()
+    value = float(series.iloc[-1])
+    if math.isnan(value):
+        raise NotEnoughData(name)
+    return value
+
+def compile_expression(source, timeframe, timeframes):
+    return Expression(source, timeframe, list(timeframes))
+
+def frames_from(candles_by_timeframe):
+    """DataFrames for Expression.evaluate from lists of candle dicts"""
+    return {tf: pd.DataFrame(candles) for tf, candles in candles_by_timeframe.items()}
@@ -0,0 +1,146 @@
+"""The indicators computed on every timeframe, kept in a registry new ones are added to.
+
+An indicator turns a timeframe's candles, a DataFrame oldest first with time, open, high, low,
+close and volume columns, into its value at the last candle: a number, a dict of numbers (bands,
+lines) or None when it has none. Its params are defaults that /api/v1/indicators/config can change
+per timeframe, compute gets the ones in force and isn't called before warmup candles are in.
+
+Indicators are computed when a candle closes, over the closed candles, and hold that value until the
+next close. One that's cheap to follow the forming candle sets intrabar, and is then updated from
+its value at the close (settled) as trades come in: update recomputes it by default, an indicator
+that can do better (an EMA only needs its settled value and the price) overrides it.
+
+Adding your own:
+
+    class Momentum(Indicator):
+        name = 'MOM'
+        description = 'Close minus the close period candles earlier'
+        params = {'period': 10}
+
+        def compute(self, df, p):
+            return float(df['close'].iloc[-1] - df['close'].iloc[-1 - p['period']])
+
+    indicator_registry.register(Momentum())
+
+WASM plugins exporting an indicator are registered the same way, see plugins.py. Every registered
+indicator is computed, sent to the dashboard and can be alerted on, in the order it registered.
+"""
+
+# What the value is, which decides how the dashboard treats it
+LEVEL = 'level'  # A price, alerted on when price comes near and plannable from
+BANDS = 'bands'  # A dict of prices, each band alerted on like a level
+VALUE = 'value'  # Anything else
+
+class Indicator:
+    name = ''
+    description = ''
+    params = {}  # Defaults, every key can be changed per timeframe
+    kind = VALUE
+    intrabar = False  # Follows the forming candle, rather than only changing when a candle closes
+
+    def warmup(self, p):
+        """Candles needed before the value means anything, the longest period by default"""
+        return max([v for v in p.values() if isinstance(v, int)] or [1])
+
+    def compute(self, df, p):
+        raise NotImplementedError
+
+    def update(self, df, p, settled):
+        """Value with the forming candle, the last row of df, given settled, the value at the last close"""
+        return self.compute(df, p)
+
+    def describe(self):
+        return {'name': self.name, 'description': self.description, 'kind': self.kind, 'params': dict(self.params),
+                'intrabar': self.intrabar}
+
+class FunctionIndicator(Indicator):
+    """An indicator computed by function(df, **p), with warmup a number of candles or a function of p.
+    intrabar can be True, or a function(df, settled, **p) updating the value with the forming candle"""
+    def __init__(self, name, function, params=None, kind=VALUE, description='', warmup=None, intrabar=False):
+        self.name = name
+        self.function = function
+        self.params = params or {}
+        self.kind = kind
+        self.description = description
+        self._warmup = warmup
+        self.intrabar = bool(intrabar)
+        self.updater = intrabar if callable(intrabar) else None
+
+    def warmup(self, p):
+        if self._warmup is None:
+            return super().warmup(p)
+        return self._warmup(p) if callable(self._warmup) else self._warmup
+
+    def compute(self, df, p):
+        return self.function(df, **p)
+
+    def update(self, df, p, settled):
+        if self.updater is None or settled is None:
+            return self.compute(df, p)
+        return self.updater(df, settled, **p)
+
+class IndicatorRegistry:
+    def __init__(self):
+        self.indicators = {}
+        self.names = []  # In registration order. The same list throughout, so it can be held on to
+
+    def register(self, indicator):
+        if not indicator.name or indicator.name in self.indicators:
+            raise ValueError(f"an indicator needs a name of its own, {indicator.name!r} isn't")
+        self.indicators[indicator.name] = indicator
+        self.names.append(indicator.name)
+        return indicator
+
+    def __getitem__(self, name):
+        return self.indicators[name]
+
+    def __contains__(self, name):
+        return name in self.indicators
+
+    def of_kind(self, kind):
+        return [name for name in self.names if self.indicators[name].kind == kind]
+
+    def defaults(self):
+        return {name: dict(self.indicators[name].params) for name in self.names}
+
+    def compute(self, df, params=None, on_error=None):
+        """Every indicator's value at the last candle of df with params ({name: parameters}, the defaults
+        for those left out), None before its warmup. With on_error, an indicator that raises is reported
+        to on_error(name, exception) and left None"""
+        values = {}
+        for name in self.names:
+            indicator = self.indicators[name]
+            p = (params or {}).get(name, indicator.params)
+            values[name] = None
+            if len(df) < indicator.warmup(p):
+                continue
+            try:
+                values[name] = indicator.compute(df, p)
+            except Exception as e:
+                if on_error is None:
+                    raise
+                on_error(name, e)
+        return values
+
+    def update(self, df, settled, params=None, on_error=None):
+        """Values with the forming candle, the last row of df, from settled, the values computed at the last
+        close: intrabar indicators are updated, the others keep their settled value"""
+        values = {}
+        for name in self.names:
+            indicator = self.indicators[name]
+            p = (params or {}).get(name, indicator.params)
+            values[name] = settled.get(name)
+            if not indicator.intrabar or len(df) < indicator.warmup(p):
+                continue
+            try:
+                values[name] = indicator.update(df, p, settled.get(name))
+            except Exception as e:
+                if on_error is None:
+                    raise
+                on_error(name, e)
+        return values
+
+    def describe(self):
+        return [self.indicators[name].describe() for name in self.names]
+
+indicator_registry = IndicatorRegistry()
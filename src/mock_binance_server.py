@@ -0,0 +1,345 @@
+"""Mock Binance server for local end-to-end testing.
+
+Serves a synthetic BTCUSDT market over the same endpoints the dashboard uses:
+
+    GET  /api/v3/klines           REST klines (symbol, interval, limit, startTime), also /fapi/v1/klines
+    GET  /fapi/v1/exchangeInfo    BTCUSDT with a 0.01 tick and 0.001 lot size
+    GET  /fapi/v1/ticker/24hr     24h ticker, also /api/v3/ticker/24hr
+    GET  /fapi/v1/ticker/price    last price
+    GET  /fapi/v1/premiumIndex    mark price and funding
+    GET  /fapi/v1/openInterest    open interest
+    GET  /futures/data/globalLongShortAccountRatio, /futures/data/takerlongshortRatio  5m positioning
+    WS   /ws/btcusdt@aggTrade     aggTrade stream
+    WS   /stream?streams=a/b      combined streams: <symbol>@aggTrade, <symbol>@markPrice@1s,
+                                  <symbol>@miniTicker, !ticker@arr and !markPrice@arr, with SUBSCRIBE and
+                                  UNSUBSCRIBE requests answered
+
+plus a few control endpoints so tests can steer the market:
+
+    POST /mock/price              {"price": 65000} jump the price
+    POST /mock/drop               close every open stream (simulates a disconnect)
+    GET  /mock/stats              connections and trades served
+
+Run it and point the dashboard at it:
+
+    python mock_binance_server.py --port 9001
+    BINANCE_REST_URL=http://127.0.0.1:9001 BINANCE_FUTURES_URL=http://127.0.0.1:9001 \
+        BINANCE_WS_URL=ws://127.0.0.1:9001 BINANCE_SPOT_WS_URL=ws://127.0.0.1:9001 python btc_alert_dashboard_web.py
+"""
+from flask import Flask, jsonify, request, Response
+from simple_websocket import Server, ConnectionClosed
+import argparse
+import json
+import math
+import random
+import threading
+import time
+
+INTERVAL_SECONDS = {
+    '1m': 60,
+    '3m': 180,
+    '5m': 300,
+    '15m': 900,
+    '30m': 1800,
+    '1h': 3600,
+    '2h': 7200,
+    '4h': 14400,
+    '1d': 86400
+}
+SYMBOL = 'BTCUSDT'
+FUNDING_INTERVAL = 8 * 3600 * 1000
+
+class MockMarket:
+    def __init__(self, base_price=65000.0, trades_per_second=5, seed=42):
+        self.base_price = base_price
+        self.trades_per_second = trades_per_second
+        self.seed = seed
+        self.offset = 0.0  # Shift applied by /mock/price on top of the synthetic path
+        self.trade_id = 0
+        self.trades_served = 0
+        self.connections = set()
+        self.lock = threading.Lock()
+        self.rng = random.Random(seed)
+
+    def price_at(self, ms):
+        """Deterministic slow-moving price path so klines and live trades line up"""
+        hours = ms / 3_600_000
+        drift = 0.02 * math.sin(hours / 6) + 0.005 * math.sin(hours * 2)
+        return self.base_price * (1 + drift) + self.offset
+
+    def set_price(self, price):
+        now = int(time.time() * 1000)
+        self.offset += float(price) - self.price_at(now)
+
+    def kline(self, open_ms, seconds):
+        rng = random.Random(f"{self.seed}-{seconds}-{open_ms}")
+        close_ms = open_ms + seconds * 1000 - 1
+        open_price = self.price_at(open_ms)
+        close_price = self.price_at(min(close_ms, int(time.time() * 1000)))
+        spread = open_price * 0.0004 * math.sqrt(seconds / 60)
+        high = max(open_price, close_price) + rng.random() * spread
+        low = min(open_price, close_price) - rng.random() * spread
+        volume = rng.uniform(5, 50) * seconds / 60
+        return [
+            open_ms, f"{open_price:.2f}", f"{high:.2f}", f"{low:.2f}", f"{close_price:.2f}",
+            f"{volume:.3f}", close_ms, f"{volume * close_price:.2f}", int(volume * 10),
+            f"{volume / 2:.3f}", f"{volume / 2 * close_price:.2f}", "0"
+        ]
+
+    def klines(self, interval, limit=500, start_time=None):
+        seconds = INTERVAL_SECONDS[interval]
+        step = seconds * 1000
+        now = int(time.time() * 1000)
+        current = now - now % step
+        if start_time is None:
+            first = current - (limit - 1) * step
+        else:
+            first = int(start_time) - int(start_time) % step
+            if int(start_time) % step:
+                first += step
+        opens = range(first, current + 1, step)
+        return [self.kline(t, seconds) for t in list(opens)[:limit]]
+
+    def next_trade(self):
+        with self.lock:
+            self.trade_id += 1
+            self.trades_served += 1
+            trade_id = self.trade_id
+        now = int(time.time() * 1000)
+        price = self.price_at(now) * (1 + self.rng.uniform(-0.0002, 0.0002))
+        return {
+            'e': 'aggTrade',
+            'E': now,
+            's': 'BTCUSDT',
+            'a': trade_id,
+            'p': f"{price:.2f}",
+            'q': f"{self.rng.uniform(0.001, 0.5):.3f}",
+            'f': trade_id,
+            'l': trade_id,
+            'T': now,
+            'm': self.rng.random() < 0.5
+        }
+
+    def exchange_info(self):
+        return {'timezone': 'UTC', 'serverTime': int(time.time() * 1000), 'symbols': [{
+            'symbol': SYMBOL, 'status': 'TRADING', 'baseAsset': 'BTC', 'quoteAsset': 'USDT',
+            'pricePrecision': 2, 'quantityPrecision': 3,
+            'filters': [
+                {'filterType': 'PRICE_FILTER', 'tickSize': '0.01', 'minPrice': '0.01', 'maxPrice': '1000000'},
+                {'filterType': 'LOT_SIZE', 'stepSize': '0.001', 'minQty': '0.001', 'maxQty': '1000'},
+                {'filterType': 'MIN_NOTIONAL', 'notional': '100'}
+            ]
+        }]}
+
+    def ticker(self):
+        """24h ticker, in the REST field names"""
+        now = int(time.time() * 1000)
+        last, opened = self.price_at(now), self.price_at(now - 86_400_000)
+        prices = [self.price_at(now - h * 3_600_000) for h in range(25)]
+        volume = 24 * 60 * 27.5
+        return {'symbol': SYMBOL, 'lastPrice': f"{last:.2f}", 'openPrice': f"{opened:.2f}",
+                'priceChange': f"{last - opened:.2f}", 'priceChangePercent': f"{(last - opened) / opened * 100:.3f}",
+                'highPrice': f"{max(prices):.2f}", 'lowPrice': f"{min(prices):.2f}", 'volume': f"{volume:.3f}",
+                'quoteVolume': f"{volume * last:.2f}", 'openTime': now - 86_400_000, 'closeTime': now}
+
+    def premium_index(self):
+        """Mark price and funding, in the REST field names"""
+        now = int(time.time() * 1000)
+        mark = self.price_at(now)
+        return {'symbol': SYMBOL, 'markPrice': f"{mark:.2f}", 'indexPrice': f"{mark * 0.9998:.2f}",
+                'lastFundingRate': '0.00010000', 'nextFundingTime': now - now % FUNDING_INTERVAL + FUNDING_INTERVAL,
+                'interestRate': '0.00010000', 'time': now}
+
+    def open_interest(self):
+        return {'symbol': SYMBOL, 'openInterest': f"{80000 + 5000 * math.sin(time.time() / 3600):.3f}",
+                'time': int(time.time() * 1000)}
+
+    def positioning(self, metric, limit):
+        """Latest limit 5m points of long_short or taker, oldest first"""
+        step = 300_000
+        now = int(time.time() * 1000)
+        points = []
+        for t in range(now - now % step - (limit - 1) * step, now + 1, step):
+            rng = random.Random(f"{self.seed}-{metric}-{t}")
+            if metric == 'long_short':
+                longs = rng.uniform(0.4, 0.7)
+                points.append({'symbol': SYMBOL, 'longShortRatio': f"{longs / (1 - longs):.4f}",
+                               'longAccount': f"{longs:.4f}", 'shortAccount': f"{1 - longs:.4f}", 'timestamp': t})
+            else:
+                buy, sell = rng.uniform(100, 500), rng.uniform(100, 500)
+                points.append({'buySellRatio': f"{buy / sell:.4f}", 'buyVol': f"{buy:.3f}",
+                               'sellVol': f"{sell:.3f}", 'timestamp': t})
+        return points
+
+    def stream_event(self, stream):
+        """The next message of a combined stream, in the stream field names"""
+        now = int(time.time() * 1000)
+        stream = stream.lower()
+        if stream.endswith('@aggtrade'):
+            return self.next_trade()
+        ticker, index = self.ticker(), self.premium_index()
+        mark = {'e': 'markPriceUpdate', 'E': now, 's': SYMBOL, 'p': index['markPrice'], 'i': index['indexPrice'],
+                'P': index['markPrice'], 'r': index['lastFundingRate'], 'T': index['nextFundingTime']}
+        if stream.endswith('@markprice@1s'):
+            return mark
+        if stream == '!markprice@arr':
+            return [mark]
+        full = {'e': '24hrTicker', 'E': now, 's': SYMBOL, 'c': ticker['lastPrice'], 'o': ticker['openPrice'],
+                'h': ticker['highPrice'], 'l': ticker['lowPrice'], 'v': ticker['volume'], 'q': ticker['quoteVolume'],
+                'p': ticker['priceChange'], 'P': ticker['priceChangePercent']}
+        if stream == '!ticker@arr':
+            return [full]
+        if stream.endswith('@miniticker'):
+            return {**{k: v for k, v in full.items() if k not in ('p', 'P')}, 'e': '24hrMiniTicker'}
+        return None
+
+def create_app(market):
+    app = Flask(__name__)
+
+    @app.route('/api/v3/klines')
+    @app.route('/fapi/v1/klines')
+    def klines():
+        interval = request.args.get('interval', '1m')
+        if interval not in INTERVAL_SECONDS:
+            return jsonify({'code': -1120, 'msg': 'Invalid interval.'}), 400
+        limit = min(int(request.args.get('limit', 500)), 1000)
+        return jsonify(market.klines(interval, limit, request.args.get('startTime')))
+
+    @app.route('/fapi/v1/exchangeInfo')
+    def exchange_info():
+        return jsonify(market.exchange_info())
+
+    @app.route('/api/v3/ticker/24hr')
+    @app.route('/fapi/v1/ticker/24hr')
+    def ticker_24hr():
+        ticker = market.ticker()
+        symbols = json.loads(request.args['symbols']) if 'symbols' in request.args else None
+        symbol = request.args.get('symbol')
+        if (symbols and SYMBOL not in symbols) or (symbol and symbol != SYMBOL):
+            return jsonify({'code': -1121, 'msg': 'Invalid symbol.'}), 400
+        return jsonify(ticker if symbol else [ticker])
+
+    @app.route('/fapi/v1/ticker/price')
+    def ticker_price():
+        price = {'symbol': SYMBOL, 'price': market.ticker()['lastPrice'], 'time': int(time.time() * 1000)}
+        return jsonify(price if request.args.get('symbol') else [price])
+
+    @app.route('/fapi/v1/premiumIndex')
+    def premium_index():
+        index = market.premium_index()
+        return jsonify(index if request.args.get('symbol') else [index])
+
+    @app.route('/fapi/v1/openInterest')
+    def open_interest():
+        return jsonify(market.open_interest())
+
+    @app.route('/futures/data/globalLongShortAccountRatio')
+    @app.route('/futures/data/takerlongshortRatio')
+    def positioning():
+        metric = 'long_short' if request.path.endswith('AccountRatio') else 'taker'
+        limit = min(int(request.args.get('limit', 30)), 500)
+        return jsonify(market.positioning(metric, limit))
+
+    @app.route('/ws/<stream>')
+    def stream(stream):
+        ws = Server.accept(request.environ)
+        with market.lock:
+            market.connections.add(ws)
+        try:
+            while True:
+                ws.send(json.dumps(market.next_trade()))
+                time.sleep(1 / market.trades_per_second)
+        except ConnectionClosed:
+            pass
+        finally:
+            with market.lock:
+                market.connections.discard(ws)
+
+        class WebSocketResponse(Response):
+            def __call__(self, *args, **kwargs):
+                # The socket was taken over by the websocket, tell werkzeug not to write a response
+                raise ConnectionError()
+        return WebSocketResponse()
+
+    @app.route('/stream')
+    def combined_stream():
+        streams = {s for s in request.args.get('streams', '').split('/') if s}
+        ws = Server.accept(request.environ)
+        with market.lock:
+            market.connections.add(ws)
+        last_second = 0
+        try:
+            while True:
+                request_message = ws.receive(timeout=0)
+                while request_message:
+                    subscription = json.loads(request_message)
+                    params = set(subscription.get('params', []))
+                    if subscription.get('method') == 'SUBSCRIBE':
+                        streams |= params
+                    elif subscription.get('method') == 'UNSUBSCRIBE':
+                        streams -= params
+                    ws.send(json.dumps({'result': None, 'id': subscription.get('id')}))
+                    request_message = ws.receive(timeout=0)
+                # Trades at the market's rate, everything else every second like Binance
+                second = int(time.time())
+                for stream in sorted(streams):
+                    if stream.lower().endswith('@aggtrade') or second != last_second:
+                        data = market.stream_event(stream)
+                        if data is not None:
+                            ws.send(json.dumps({'stream': stream, 'data': data}))
+                last_second = second
+                time.sleep(1 / market.trades_per_second)
+        except ConnectionClosed:
+            pass
+        finally:
+            with market.lock:
+                market.connections.discard(ws)
+
+        class WebSocketResponse(Response):
+            def __call__(self, *args, **kwargs):
+                raise ConnectionError()
+        return WebSocketResponse()
+
+    @app.route('/mock/price', methods=['POST'])
+    def mock_price():
+        market.set_price(request.json['price'])
+        return jsonify({'status': 'success'})
+
+    @app.route('/mock/drop', methods=['POST'])
+    def mock_drop():
+        with market.lock:
+            connections = list(market.connections)
+        for ws in connections:
+            ws.close()
+        return jsonify({'status': 'success', 'dropped': len(connections)})
+
+    @app.route('/mock/stats')
+    def mock_stats():
+        with market.lock:
+            return jsonify({
+                'connections': len(market.connections),
+                'trades_served': market.trades_served
+            })
+
+    return app
+
+def run_in_thread(market, port):
+    app = create_app(market)
+    thread = threading.Thread(
+        target=lambda: app.run(host='127.0.0.1', port=port, threaded=True),
+        daemon=True
+    )
+    thread.start()
+    return thread
+
+if __name__ == '__main__':
+    parser = argparse.ArgumentParser(description='Mock Binance server')
+    parser.add_argument('--port', type=int, default=9001)
+    parser.add_argument('--price', type=float, default=65000.0, help='base price of the synthetic market')
+    parser.add_argument('--rate', type=float, default=5, help='trades per second per stream')
+    parser.add_argument('--seed', type=int, default=42)
+    args = parser.parse_args()
+
+    market = MockMarket(args.price, args.rate, args.seed)
+    print(f"Mock Binance server on http://127.0.0.1:{args.port}")
+    create_app(market).run(host='127.0.0.1', port=args.port, threaded=True)
@@ -0,0 +1,149 @@
+"""End-to-end checks of the dashboard against mock_binance_server.py.
+
+Starts the mock exchange, launches the dashboard pointed at it in a scratch
+directory, connects as a Socket.IO client and walks through the trade
+aggregation, alert and reconnection pipelines, then checks a sample of every
+socket message against the schemas it serves (see ws_contract.py). No real
+exchange is contacted.
+
+    python mock_harness.py
+"""
+import argparse
+import os
+import subprocess
+import sys
+import tempfile
+import threading
+import time
+
+import requests
+import socketio
+
+from mock_binance_server import MockMarket, run_in_thread
+import ws_contract
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+
+class EventLog:
+    def __init__(self):
+        self.events = []
+        self.condition = threading.Condition()
+
+    def add(self, name, data):
+        with self.condition:
+            self.events.append((name, data))
+            self.condition.notify_all()
+
+    def wait_for(self, name, predicate=lambda data: True, timeout=30, since=0):
+        """Wait for an event recorded after index `since` and return its index"""
+        deadline = time.time() + timeout
+        with self.condition:
+            while True:
+                for i in range(since, len(self.events)):
+                    event, data = self.events[i]
+                    if event == name and predicate(data):
+                        return i
+                remaining = deadline - time.time()
+                if remaining <= 0:
+                    raise TimeoutError(f"no {name} event within {timeout}s")
+                self.condition.wait(remaining)
+
+def wait_for_http(url, timeout=30):
+    deadline = time.time() + timeout
+    while time.time() < deadline:
+        try:
+            requests.get(url, timeout=1)
+            return
+        except requests.RequestException:
+            time.sleep(0.2)
+    raise TimeoutError(f"{url} did not come up within {timeout}s")
+
+def run_checks(dashboard_url, mock_url, log):
+    def check(name, fn):
+        try:
+            fn()
+            print(f"PASS  {name}")
+            return True
+        except Exception as e:
+            print(f"FAIL  {name}: {e}")
+            return False
+
+    results = []
+
+    def price_updates():
+        log.wait_for('price_update')
+    results.append(check('live trades produce price updates', price_updates))
+
+    def indicators():
+        log.wait_for('indicators_update', lambda d: '1m' in d['indicators'], timeout=20)
+    results.append(check('klines backfill feeds indicators', indicators))
+
+    def price_alert():
+        since = len(log.events)
+        target = 70000.0
+        requests.post(f"{dashboard_url}/api/v1/price-alerts", json={'price': target})
+        requests.post(f"{mock_url}/mock/price", json={'price': target})
+        log.wait_for('alert', lambda d: 'Price reached 70000.00' in d['message'], since=since)
+    results.append(check('price alert fires when the market reaches it', price_alert))
+
+    def reconnect():
+        since = len(log.events)
+        requests.post(f"{mock_url}/mock/drop")
+        log.wait_for('status', lambda d: d['message'] == 'Disconnected from Binance', since=since)
+        log.wait_for('status', lambda d: d['message'] == 'Connected to Binance', since=since, timeout=30)
+        log.wait_for('price_update', since=len(log.events))
+    results.append(check('dashboard reconnects after the stream drops', reconnect))
+
+    def message_contract():
+        from btc_alert_dashboard_web import schema_errors
+        failures = ws_contract.check(ws_contract.served_schemas(dashboard_url), schema_errors)
+        if failures:
+            raise AssertionError('; '.join(failures))
+    results.append(check('socket message samples match the served schemas', message_contract))
+
+    return all(results)
+
+def main():
+    parser = argparse.ArgumentParser(description='Run the dashboard against the mock exchange')
+    parser.add_argument('--mock-port', type=int, default=9001)
+    parser.add_argument('--port', type=int, default=5055, help='port for the dashboard under test')
+    args = parser.parse_args()
+
+    market = MockMarket(base_price=65000.0, trades_per_second=10)
+    run_in_thread(market, args.mock_port)
+    mock_url = f"http://127.0.0.1:{args.mock_port}"
+    wait_for_http(f"{mock_url}/mock/stats")
+
+    # Every exchange URL points at the mock, so nothing reaches Binance and prices keep the mock's 0.01 tick
+    env = dict(os.environ,
+               BINANCE_REST_URL=mock_url,
+               BINANCE_FUTURES_URL=mock_url,
+               BINANCE_WS_URL=f"ws://127.0.0.1:{args.mock_port}",
+               BINANCE_SPOT_WS_URL=f"ws://127.0.0.1:{args.mock_port}")
+    workdir = tempfile.mkdtemp(prefix='cryptic-harness-')
+    dashboard = subprocess.Popen(
+        [sys.executable, os.path.join(HERE, 'btc_alert_dashboard_web.py'), '--port', str(args.port)],
+        cwd=workdir, env=env
+    )
+    dashboard_url = f"http://127.0.0.1:{args.port}"
+
+    log = EventLog()
+    client = socketio.Client()
+    for event in ('price_update', 'indicators_update', 'alert', 'status', 'error'):
+        client.on(event, lambda data=None, event=event: log.add(event, data))
+
+    try:
+        wait_for_http(f"{dashboard_url}/api/v1/status")
+        client.connect(dashboard_url)
+        ok = run_checks(dashboard_url, mock_url, log)
+    finally:
+        if client.connected:
+            client.disconnect()
+        dashboard.terminate()
+        dashboard.wait(timeout=10)
+
+    print('All checks passed' if ok else 'Some checks failed')
+    sys.exit(0 if ok else 1)
+
+if __name__ == '__main__':
+    main()
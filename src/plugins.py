@@ -0,0 +1,158 @@
+"""Indicators and strategies from WebAssembly modules, added without touching CRYPTIC.
+
+Drop a .wasm file in the --plugins directory. It is named after the file and
+gets no imports at all (no WASI, no host calls), so it can only compute. It
+exports:
+
+    memory                                 its linear memory
+    alloc(bytes: i32) -> i32               room for the candles, called before every call
+    indicator(ptr: i32, count: i32) -> f64     an indicator: its value at the last candle, NaN for none
+    signal(ptr: i32, count: i32) -> i32        a strategy: 0 nothing, 1 LONG, 2 SHORT, 3 EXIT
+    warmup() -> i32                        optional, candles needed before the first value means anything
+
+and reads count candles at ptr, oldest first, each five little-endian f64:
+open time (epoch ms), open, high, low, close. A module may export both
+indicator and signal. A bump allocator that starts over on every alloc is
+enough, nothing is kept between calls.
+
+Every call runs on a fuel budget, so a plugin stuck in a loop is stopped
+rather than hanging the feed.
+"""
+import math
+import os
+import struct
+import threading
+
+import pandas as pd
+
+from indicators import Indicator
+from strategies import EXIT, LONG, SHORT, Strategy
+
+PLUGIN_FUEL = 50_000_000  # Wasm instructions, roughly, one call may run
+CANDLE_FORMAT = '<5d'
+SIGNALS = {0: None, 1: LONG, 2: SHORT, 3: EXIT}
+
+def epoch_ms(value):
+    return value.timestamp() * 1000 if isinstance(value, pd.Timestamp) else float(value)
+
+class PluginError(ValueError):
+    """The module can't be loaded or broke the ABI"""
+
+class WasmPlugin:
+    def __init__(self, path):
+        try:
+            import wasmtime
+        except ImportError:
+            raise PluginError('WASM plugins need: pip install wasmtime')
+        self.path = path
+        self.name = os.path.splitext(os.path.basename(path))[0]
+        self.lock = threading.Lock()  # A store is single threaded
+        config = wasmtime.Config()
+        config.consume_fuel = True
+        self.engine = wasmtime.Engine(config)
+        self.store = wasmtime.Store(self.engine)
+        self.trap = (wasmtime.Trap, wasmtime.WasmtimeError)
+        try:
+            module = wasmtime.Module.from_file(self.engine, path)
+            if module.imports:
+                raise PluginError(f"{self.name} imports {module.imports[0].module}.{module.imports[0].name}, "
+                                  f"plugins get no imports")
+            exports = wasmtime.Instance(self.store, module, []).exports(self.store)
+        except wasmtime.WasmtimeError as e:
+            raise PluginError(f"{self.name}: {e}")
+        names = {export.name for export in module.exports}
+        missing = {'memory', 'alloc'} - names
+        if missing or not names & {'indicator', 'signal'}:
+            raise PluginError(f"{self.name} must export memory, alloc and indicator and/or signal")
+        self.memory = exports['memory']
+        self.alloc = exports['alloc']
+        self.functions = {name: exports[name] for name in ('indicator', 'signal') if name in names}
+        self.warmup = 1
+        if 'warmup' in names:
+            self.warmup = max(1, int(self.run(exports['warmup'])))
+
+    @property
+    def kinds(self):
+        return sorted(self.functions)
+
+    def run(self, fn, *args):
+        self.store.set_fuel(PLUGIN_FUEL)
+        try:
+            return fn(self.store, *args)
+        except self.trap as e:
+            raise PluginError(f"{self.name} trapped: {e}")
+
+    def load(self, candles):
+        """Write candles into the module's memory, returning the pointer"""
+        data = b''.join(struct.pack(CANDLE_FORMAT, epoch_ms(c['time']), c['open'], c['high'], c['low'], c['close'])
+                        for c in candles)
+        ptr = self.run(self.alloc, len(data))
+        if ptr < 0 or ptr + len(data) > self.memory.data_len(self.store):
+            raise PluginError(f"{self.name} alloc returned memory it doesn't have")
+        self.memory.write(self.store, data, ptr)
+        return ptr
+
+    def indicator(self, candles):
+        """Value at the last candle, None before warmup or when the plugin has none"""
+        if len(candles) < self.warmup:
+            return None
+        with self.lock:
+            value = self.run(self.functions['indicator'], self.load(candles), len(candles))
+        return None if math.isnan(value) else float(value)
+
+    def signals(self, candles):
+        """Signal at each candle, computed over the candles up to it"""
+        result = [None] * len(candles)
+        with self.lock:
+            ptr = self.load(candles)
+            for count in range(self.warmup, len(candles) + 1):
+                code = self.run(self.functions['signal'], ptr, count)
+                if code not in SIGNALS:
+                    raise PluginError(f"{self.name} returned signal {code}, expected 0 to 3")
+                result[count - 1] = SIGNALS[code]
+        return result
+
+    def strategy_class(self):
+        """A Strategy the backtester and paper trader use like the built-in ones"""
+        plugin = self
+
+        class PluginStrategy(Strategy):
+            name = plugin.name
+            description = f"WASM plugin {os.path.basename(plugin.path)}"
+            params = {}
+
+            @property
+            def warmup(self):
+                return plugin.warmup
+
+            def signals(self, df):
+                return pd.Series(plugin.signals(df.to_dict('records')), index=df.index, dtype=object)
+
+        return PluginStrategy
+
+    def indicator_class(self):
+        """An Indicator for the registry, computed with the built-in ones on every timeframe"""
+        plugin = self
+
+        class PluginIndicator(Indicator):
+            name = plugin.name
+            description = f"WASM plugin {os.path.basename(plugin.path)}"
+
+            def warmup(self, p):
+                return plugin.warmup
+
+            def compute(self, df, p):
+                value = plugin.indicator(df.to_dict('records'))
+                return None if value is None else round(value, 8)
+
+        return PluginIndicator
+
+    def describe(self):
+        return {'name': self.name, 'file': os.path.basename(self.path), 'kinds': self.kinds, 'warmup': self.warmup}
+
+def load_plugins(directory):
+    """Every plugin in directory, raising PluginError for the first one that doesn't load"""
+    if not os.path.isdir(directory):
+        raise PluginError(f"plugins directory {directory} does not exist")
+    return [WasmPlugin(os.path.join(directory, name)) for name in sorted(os.listdir(directory))
+            if name.endswith('.wasm')]
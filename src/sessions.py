@@ -0,0 +1,134 @@
+"""Trading sessions and their open, high, low and close, for session levels and break alerts.
+
+Sessions are daily windows of local time, written like
+
+    asia=00:00-09:00,london=07:00-16:00,new_york=13:30-20:00
+
+in one timezone: UTC by default, or any IANA name such as Europe/London, in which case the
+windows follow daylight saving. A window may wrap past midnight (sydney=22:00-07:00) and
+sessions may overlap.
+
+SessionBook follows trades and keeps, per session, the instance in progress and the last one
+that completed. A break is the price trading beyond the high or low of a session's last
+completed instance, reported once per instance and side.
+"""
+import datetime
+import re
+
+DEFAULT_SESSIONS = 'asia=00:00-09:00,london=07:00-16:00,new_york=13:30-20:00'
+SESSION = re.compile(r'^([a-z][a-z0-9_]*)=(\d{1,2}):(\d{2})-(\d{1,2}):(\d{2})$')
+
+class SessionError(ValueError):
+    """A session list or timezone that can't be used"""
+
+def load_timezone(name):
+    try:
+        from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+    except ImportError:  # Before Python 3.9
+        if name.upper() == 'UTC':
+            return datetime.timezone.utc
+        raise SessionError('session timezones other than UTC need Python 3.9')
+    try:
+        return ZoneInfo(name)
+    except (ZoneInfoNotFoundError, ValueError):
+        raise SessionError(f"unknown timezone {name!r}, use an IANA name like Europe/London")
+
+class Session:
+    def __init__(self, name, start, end):
+        if start == end:
+            raise SessionError(f"session {name} starts when it ends")
+        self.name = name
+        self.start = start
+        self.end = end
+
+    def window(self, ts, tz):
+        """(start, end) in epoch ms of the instance running at ts, else of the last one before it"""
+        local = datetime.datetime.fromtimestamp(ts / 1000, tz)
+        for days in (0, -1):
+            day = local.date() + datetime.timedelta(days=days)
+            start = datetime.datetime.combine(day, self.start, tz)
+            if start <= local:
+                end_day = day + datetime.timedelta(days=1 if self.end < self.start else 0)
+                end = datetime.datetime.combine(end_day, self.end, tz)
+                return int(start.timestamp() * 1000), int(end.timestamp() * 1000)
+
+    def describe(self):
+        return {'name': self.name, 'start': self.start.strftime('%H:%M'), 'end': self.end.strftime('%H:%M')}
+
+def parse_sessions(spec):
+    sessions = []
+    for item in spec.split(','):
+        match = SESSION.match(item.strip().lower())
+        if not match:
+            raise SessionError(f"can't read session {item.strip()!r}, expected name=HH:MM-HH:MM")
+        name, hours, minutes = match.group(1), match.groups()[1::2], match.groups()[2::2]
+        try:
+            start, end = (datetime.time(int(h), int(m)) for h, m in zip(hours, minutes))
+        except ValueError:
+            raise SessionError(f"session {name} has a time that doesn't exist")
+        if any(session.name == name for session in sessions):
+            raise SessionError(f"session {name} is listed twice")
+        sessions.append(Session(name, start, end))
+    return sessions
+
+class SessionBook:
+    def __init__(self, sessions, timezone='UTC'):
+        self.sessions = sessions
+        self.timezone = timezone
+        self.tz = load_timezone(timezone)
+        self.current = {}  # Session name -> instance in progress
+        self.previous = {}  # Session name -> last completed instance
+        self.broken = set()  # (session name, instance start, side) already reported
+
+    @property
+    def names(self):
+        return [session.name for session in self.sessions]
+
+    def update(self, price, ts):
+        """Fold in a trade at epoch ms ts, oldest first, returning the breaks it made as
+        (session, side, level)"""
+        breaks = []
+        for session in self.sessions:
+            name = session.name
+            current = self.current.get(name)
+            if current is not None and ts >= current['end']:
+                self.previous[name] = current
+                del self.current[name]
+                self.broken = {key for key in self.broken if key[0] != name or key[1] == current['start']}
+                current = None
+            start, end = session.window(ts, self.tz)
+            if ts < end:
+                if current is None:
+                    self.current[name] = {'start': start, 'end': end, 'open': price, 'high': price, 'low': price,
+                                          'close': price}
+                else:
+                    current['high'] = max(current['high'], price)
+                    current['low'] = min(current['low'], price)
+                    current['close'] = price
+            completed = self.previous.get(name)
+            if completed is None:
+                continue
+            for side, beyond in (('high', price > completed['high']), ('low', price < completed['low'])):
+                key = (name, completed['start'], side)
+                if beyond and key not in self.broken:
+                    self.broken.add(key)
+                    breaks.append((name, side, completed[side]))
+        return breaks
+
+    def load(self, candles):
+        """Fold in 1m candles (epoch ms open time, open/high/low/close), their breaks are history"""
+        for candle in candles:
+            for price in (candle['open'], candle['high'], candle['low']):
+                self.update(price, candle['time'])
+            self.update(candle['close'], candle['time'] + 59_999)
+
+    def levels(self):
+        return {
+            'timezone': self.timezone,
+            'sessions': [{
+                **session.describe(),
+                'active': session.name in self.current,
+                'current': self.current.get(session.name),
+                'previous': self.previous.get(session.name)
+            } for session in self.sessions]
+        }
@@ -0,0 +1,629 @@
+"""Trading strategies shared by the backtester and the paper trader.
+
+A strategy turns closed candles into signals: LONG, SHORT, EXIT or None (do
+nothing). Signals are computed over the whole frame at once so a backtest
+costs one indicator pass, and the live paper trader just reads the last one.
+
+Writing your own:
+
+    class Momentum(Strategy):
+        name = 'momentum'
+        description = 'Long after N higher closes in a row'
+        params = {'bars': 3}
+
+        def signals(self, df):
+            rising = (df['close'].diff() > 0).rolling(self.p['bars']).sum() == self.p['bars']
+            return rising.map({True: LONG, False: None})
+
+    STRATEGIES[Momentum.name] = Momentum
+"""
+import itertools
+import math
+import multiprocessing
+import os
+from concurrent.futures import ProcessPoolExecutor, as_completed
+
+import pandas as pd
+from ta.momentum import RSIIndicator
+from ta.trend import EMAIndicator
+from ta.volatility import BollingerBands
+
+LONG = 'LONG'
+SHORT = 'SHORT'
+EXIT = 'EXIT'
+
+class Strategy:
+    name = ''
+    description = ''
+    params = {}  # Defaults, every key can be overridden per instance
+
+    def __init__(self, **params):
+        unknown = set(params) - set(self.params)
+        if unknown:
+            raise ValueError(f"{self.name} has no parameter {', '.join(sorted(unknown))}")
+        self.p = {}
+        for key, default in self.params.items():
+            value = params.get(key, default)
+            try:
+                self.p[key] = type(default)(value)
+            except (TypeError, ValueError):
+                raise ValueError(f"{key} must be a {type(default).__name__}")
+        self.validate()
+
+    def validate(self):
+        """Raise ValueError for parameter combinations that make no sense"""
+
+    @property
+    def warmup(self):
+        """Candles needed before the first signal means anything"""
+        return max([v for v in self.p.values() if isinstance(v, int)] or [1]) + 1
+
+    def signals(self, df):
+        """Series aligned with df of LONG/SHORT/EXIT/None, each decided at that candle's close"""
+        raise NotImplementedError
+
+    def signal(self, df):
+        if len(df) < self.warmup:
+            return None
+        value = self.signals(df).iloc[-1]
+        return value if isinstance(value, str) else None
+
+    def describe(self):
+        return {'name': self.name, 'description': self.description, 'params': self.p}
+
+def crossed_above(a, b):
+    return (a > b) & (a.shift(1) <= b.shift(1))
+
+def crossed_below(a, b):
+    return (a < b) & (a.shift(1) >= b.shift(1))
+
+def combine(index, *rules):
+    """Series of signals from (condition, signal) pairs, earlier pairs win"""
+    result = pd.Series([None] * len(index), index=index, dtype=object)
+    for condition, value in reversed(rules):
+        result[condition.fillna(False).astype(bool)] = value
+    return result
+
+class EmaCross(Strategy):
+    name = 'ema_cross'
+    description = 'Long when the fast EMA crosses above the slow one, short when it crosses below'
+    params = {'fast': 20, 'slow': 50}
+
+    def validate(self):
+        if not 0 < self.p['fast'] < self.p['slow']:
+            raise ValueError('fast must be positive and shorter than slow')
+
+    def signals(self, df):
+        fast = EMAIndicator(df['close'], window=self.p['fast']).ema_indicator()
+        slow = EMAIndicator(df['close'], window=self.p['slow']).ema_indicator()
+        return combine(df.index, (crossed_above(fast, slow), LONG), (crossed_below(fast, slow), SHORT))
+
+class RsiMeanReversion(Strategy):
+    name = 'rsi_mean_reversion'
+    description = 'Long when RSI climbs back out of oversold, short when it drops out of overbought, ' \
+                  'flat again once RSI reaches the exit level'
+    params = {'period': 14, 'oversold': 30.0, 'overbought': 70.0, 'exit': 50.0}
+
+    def validate(self):
+        if not 0 < self.p['oversold'] < self.p['exit'] < self.p['overbought'] < 100:
+            raise ValueError('need 0 < oversold < exit < overbought < 100')
+
+    def signals(self, df):
+        rsi = RSIIndicator(df['close'], window=self.p['period']).rsi()
+        return combine(
+            df.index,
+            (crossed_above(rsi, pd.Series(self.p['oversold'], index=df.index)), LONG),
+            (crossed_below(rsi, pd.Series(self.p['overbought'], index=df.index)), SHORT),
+            (crossed_above(rsi, pd.Series(self.p['exit'], index=df.index)) |
+             crossed_below(rsi, pd.Series(self.p['exit'], index=df.index)), EXIT)
+        )
+
+class BollingerBreakout(Strategy):
+    name = 'bb_breakout'
+    description = 'Long on a close above the upper band, short on a close below the lower band, ' \
+                  'exit when price returns to the middle band'
+    params = {'period': 20, 'std': 2.0}
+
+    def validate(self):
+        if self.p['period'] < 2 or self.p['std'] <= 0:
+            raise ValueError('period must be at least 2 and std positive')
+
+    def signals(self, df):
+        bb = BollingerBands(df['close'], window=self.p['period'], window_dev=self.p['std'])
+        close, middle = df['close'], bb.bollinger_mavg()
+        return combine(
+            df.index,
+            (crossed_above(close, bb.bollinger_hband()), LONG),
+            (crossed_below(close, bb.bollinger_lband()), SHORT),
+            (crossed_below(close, middle) | crossed_above(close, middle), EXIT)
+        )
+
+class Manual(Strategy):
+    name = 'manual'
+    description = 'No signals of its own, trades only on orders placed from scripts'
+
+    def signals(self, df):
+        return pd.Series([None] * len(df), index=df.index, dtype=object)
+
+STRATEGIES = {cls.name: cls for cls in (EmaCross, RsiMeanReversion, BollingerBreakout, Manual)}
+
+def create_strategy(name, params=None):
+    if name not in STRATEGIES:
+        raise ValueError(f"unknown strategy {name}, available: {', '.join(sorted(STRATEGIES))}")
+    return STRATEGIES[name](**(params or {}))
+
+SLIPPAGE_MODELS = ('fixed', 'volume')
+
+class Costs:
+    """What trading costs in a simulation, nothing by default.
+
+    Fees are in basis points of the notional, maker for take profits (resting limit orders) and taker
+    for everything else. Market fills, stops included, slip against the trade: by slippage_bps with the
+    fixed model, and with the volume model by impact_bps more for every percent of the candle's volume
+    an order of notional takes. Perpetuals pay funding_rate_percent of the position every funding_hours
+    (at 00:00, 08:00 and 16:00 UTC by default) while it's open, longs paying and shorts receiving a
+    positive rate.
+    """
+    def __init__(self, maker_fee_bps=0.0, taker_fee_bps=0.0, slippage_bps=0.0, slippage_model='fixed',
+                 impact_bps=0.0, notional=1000.0, funding_rate_percent=0.0, funding_hours=8.0):
+        if slippage_model not in SLIPPAGE_MODELS:
+            raise ValueError(f"slippage_model must be one of {', '.join(SLIPPAGE_MODELS)}")
+        if min(maker_fee_bps, taker_fee_bps, slippage_bps, impact_bps) < 0 or notional <= 0 or funding_hours <= 0:
+            raise ValueError('fees, slippage and impact can\'t be negative, notional and funding_hours must be '
+                             'positive')
+        self.maker_fee_bps = maker_fee_bps
+        self.taker_fee_bps = taker_fee_bps
+        self.slippage_bps = slippage_bps
+        self.slippage_model = slippage_model
+        self.impact_bps = impact_bps
+        self.notional = notional
+        self.funding_rate_percent = funding_rate_percent
+        self.funding_hours = funding_hours
+
+    def slippage(self, price, volume=None):
+        """Basis points a market order slips at price, in a candle that traded volume (base asset)"""
+        bps = self.slippage_bps
+        if self.slippage_model == 'volume' and volume:
+            bps += self.impact_bps * self.notional / (volume * price) * 100
+        return bps
+
+    def fill(self, price, buying, volume=None):
+        """(price a market order fills at, slippage in bps)"""
+        bps = self.slippage(price, volume)
+        return price * (1 + (bps if buying else -bps) / 10000), bps
+
+    def fundings(self, start, end):
+        """Funding times from just after start up to end"""
+        step = pd.Timedelta(hours=self.funding_hours)
+        start, end = pd.Timestamp(start), pd.Timestamp(end)
+        first = start.floor(step) + step
+        return max(0, (end - first) // step + 1) if first <= end else 0
+
+    def describe(self):
+        return dict(vars(self))
+
+class SimulatedAccount:
+    """Holds at most one position, with optional percent stop loss / take profit, and books trades.
+
+    With costs, each trade's return_percent is net of fees and funding, fills already carrying the
+    slippage, and the trade keeps what each of them cost as percents of the notional."""
+    def __init__(self, sl_percent=None, tp_percent=None, allow_short=True, costs=None):
+        self.sl_percent = sl_percent
+        self.tp_percent = tp_percent
+        self.allow_short = allow_short
+        self.costs = costs or Costs()
+        self.position = None
+        self.trades = []
+
+    def open(self, direction, price, time, volume=None):
+        sign = 1 if direction == LONG else -1
+        price, slippage = self.costs.fill(price, direction == LONG, volume)
+        self.position = {
+            'direction': direction,
+            'entry_price': price,
+            'entry_time': time,
+            'sl': price * (1 - sign * self.sl_percent / 100) if self.sl_percent else None,
+            'tp': price * (1 + sign * self.tp_percent / 100) if self.tp_percent else None,
+            'entry_fee_percent': self.costs.taker_fee_bps / 100,
+            'entry_slippage_percent': slippage / 100
+        }
+
+    def close(self, price, time, reason, volume=None):
+        position, self.position = self.position, None
+        sign = 1 if position['direction'] == LONG else -1
+        # Positions opened before costs were modelled have none booked
+        entry_fee, entry_slippage = position.pop('entry_fee_percent', 0.0), position.pop('entry_slippage_percent', 0.0)
+        slippage = 0.0
+        if reason != 'tp':
+            price, slippage = self.costs.fill(price, sign < 0, volume)
+        fees = entry_fee + (self.costs.maker_fee_bps if reason == 'tp' else self.costs.taker_fee_bps) / 100
+        funding = 0.0
+        if self.costs.funding_rate_percent:
+            funding = sign * self.costs.funding_rate_percent * self.costs.fundings(position['entry_time'], time)
+        gross = sign * (price - position['entry_price']) / position['entry_price'] * 100
+        trade = {
+            **position,
+            'exit_price': price,
+            'exit_time': time,
+            'reason': reason,
+            'gross_return_percent': gross,
+            'fees_percent': fees,
+            'slippage_percent': entry_slippage + slippage / 100,
+            'funding_percent': funding,
+            'return_percent': gross - fees - funding
+        }
+        self.trades.append(trade)
+        return trade
+
+    def apply(self, signal, price, time, volume=None):
+        """Act on a signal at price, returning the trades it closed"""
+        closed = []
+        if signal is None or (self.position and self.position['direction'] == signal):
+            return closed
+        if self.position:
+            closed.append(self.close(price, time, 'signal', volume))
+        if signal == LONG or (signal == SHORT and self.allow_short):
+            self.open(signal, price, time, volume)
+        return closed
+
+    def check_exits(self, candle):
+        """Close the position if the candle touched its stop or target, the stop wins when both were hit"""
+        if not self.position:
+            return None
+        long = self.position['direction'] == LONG
+        sl, tp = self.position['sl'], self.position['tp']
+        if sl is not None and (candle['low'] <= sl if long else candle['high'] >= sl):
+            return self.close(sl, candle['time'], 'sl', candle.get('volume'))
+        if tp is not None and (candle['high'] >= tp if long else candle['low'] <= tp):
+            return self.close(tp, candle['time'], 'tp')
+        return None
+
+def summarize(trades):
+    returns = [t['return_percent'] for t in trades]
+    equity, peak, max_drawdown = 1.0, 1.0, 0.0
+    for r in returns:
+        equity *= 1 + r / 100
+        peak = max(peak, equity)
+        max_drawdown = max(max_drawdown, (peak - equity) / peak * 100)
+    wins = [r for r in returns if r > 0]
+    return {
+        'trades': len(returns),
+        'wins': len(wins),
+        'losses': len(returns) - len(wins),
+        'win_rate': round(len(wins) / len(returns) * 100, 2) if returns else None,
+        'total_return_percent': round((equity - 1) * 100, 3),
+        'average_return_percent': round(sum(returns) / len(returns), 3) if returns else None,
+        'max_drawdown_percent': round(max_drawdown, 3)
+    }
+
+def equity_curve(trades):
+    """Equity after each trade, starting from 1 and compounding every return"""
+    equity, curve = 1.0, []
+    for t in trades:
+        equity *= 1 + t['return_percent'] / 100
+        curve.append({'time': pd.Timestamp(t['exit_time']).isoformat(), 'equity': round(equity, 6)})
+    return curve
+
+def monthly_returns(trades):
+    """Compounded return of the trades closed in each calendar month, oldest first"""
+    months = {}
+    for t in trades:
+        month = months.setdefault(pd.Timestamp(t['exit_time']).strftime('%Y-%m'), {'equity': 1.0, 'trades': 0})
+        month['equity'] *= 1 + t['return_percent'] / 100
+        month['trades'] += 1
+    return [{'month': name, 'trades': month['trades'], 'return_percent': round((month['equity'] - 1) * 100, 3)}
+            for name, month in sorted(months.items())]
+
+def report(trades):
+    """What a backtest report shows beyond the trades: key stats, equity curve and monthly returns"""
+    returns = [t['return_percent'] for t in trades]
+    gains = sum(r for r in returns if r > 0)
+    losses = -sum(r for r in returns if r < 0)
+    held = [(pd.Timestamp(t['exit_time']) - pd.Timestamp(t['entry_time'])).total_seconds() / 3600 for t in trades]
+    stats = {
+        **summarize(trades),
+        'best_trade_percent': round(max(returns), 3) if returns else None,
+        'worst_trade_percent': round(min(returns), 3) if returns else None,
+        'profit_factor': round(gains / losses, 3) if losses else None,
+        'average_hours_held': round(sum(held) / len(held), 2) if held else None,
+        # What the returns above already paid, summed over the trades in percent of the notional
+        'fees_percent': round(sum(t.get('fees_percent', 0.0) for t in trades), 3),
+        'slippage_percent': round(sum(t.get('slippage_percent', 0.0) for t in trades), 3),
+        'funding_percent': round(sum(t.get('funding_percent', 0.0) for t in trades), 3)
+    }
+    return {'stats': stats, 'equity_curve': equity_curve(trades), 'monthly_returns': monthly_returns(trades)}
+
+def backtest(strategy, candles, sl_percent=None, tp_percent=None, allow_short=True, costs=None):
+    """Run a strategy over candles (dicts with time/open/high/low/close and optionally volume, oldest
+    first), paying costs (see Costs) when given.
+
+    Signals are decided on a candle's close and filled at the next candle's open, so
+    a strategy never trades on a price it couldn't have seen.
+    """
+    df = pd.DataFrame(candles)
+    account = SimulatedAccount(sl_percent, tp_percent, allow_short, costs)
+    if len(df) <= strategy.warmup:
+        return {'strategy': strategy.describe(), 'trades': [], 'summary': summarize([])}
+
+    signals = strategy.signals(df)
+    pending = None
+    for i, candle in enumerate(candles):
+        if pending is not None:
+            account.apply(pending, candle['open'], candle['time'], candle.get('volume'))
+        account.check_exits(candle)
+        value = signals.iloc[i]
+        pending = value if i >= strategy.warmup and isinstance(value, str) else None
+    if account.position:
+        account.close(candles[-1]['close'], candles[-1]['time'], 'end', candles[-1].get('volume'))
+
+    return {'strategy': strategy.describe(), 'trades': account.trades, 'summary': summarize(account.trades)}
+
+EXIT_PARAMS = ('sl_percent', 'tp_percent')  # Grid keys that set the simulated exits rather than strategy params
+RANKINGS = ('total_return_percent', 'win_rate', 'average_return_percent', 'profit_factor', 'max_drawdown_percent')
+MIN_TRADES = 30  # Fewer trades than this and a result is mostly luck
+MAX_GRID_RUNS = 500
+MAX_WALK_FORWARD_RUNS = 5000  # Backtests of one walk-forward analysis, windows times combinations
+GRID_WORKERS = os.cpu_count() or 1  # Processes backtesting grid combinations side by side
+GRID_CHUNKS = 4  # Chunks of combinations per worker, so progress moves and one slow chunk doesn't hold up the rest
+
+def grid_values(key, spec):
+    """A grid axis from a list of values or {"from", "to", "step"}"""
+    if isinstance(spec, dict):
+        try:
+            start, stop, step = float(spec['from']), float(spec['to']), float(spec['step'])
+        except (KeyError, TypeError, ValueError):
+            raise ValueError(f"{key} needs numbers from, to and step")
+        if not all(map(math.isfinite, (start, stop, step))) or step <= 0 or stop < start:
+            raise ValueError(f"{key} needs a positive step and to at least from")
+        # Counted before anything is built, a fine step over a wide range would otherwise fill the memory
+        count = int(round((stop - start) / step, 9)) + 1
+        if count > MAX_GRID_RUNS:
+            raise ValueError(f"{key} has {count} values, at most {MAX_GRID_RUNS} combinations are run")
+        values = [round(start + i * step, 9) for i in range(count)]
+        if all(isinstance(spec[k], int) for k in ('from', 'to', 'step')):
+            return [int(v) for v in values]
+        return values
+    if not isinstance(spec, list) or not spec:
+        raise ValueError(f"{key} must be a list of values or {{from, to, step}}")
+    if len(spec) > MAX_GRID_RUNS:
+        raise ValueError(f"{key} has {len(spec)} values, at most {MAX_GRID_RUNS} combinations are run")
+    return spec
+
+def grid_axes(name, grid):
+    """(keys, values of each, number of combinations) of grid for strategy name, raising ValueError for a
+    grid that can't be searched"""
+    if name not in STRATEGIES:
+        raise ValueError(f"unknown strategy {name}, available: {', '.join(sorted(STRATEGIES))}")
+    if not isinstance(grid, dict) or not grid:
+        raise ValueError('grid needs at least one parameter')
+    unknown = set(grid) - set(STRATEGIES[name].params) - set(EXIT_PARAMS)
+    if unknown:
+        raise ValueError(f"{name} has no parameter {', '.join(sorted(unknown))}")
+    keys = sorted(grid)
+    axes = [grid_values(key, grid[key]) for key in keys]
+    total = math.prod(len(axis) for axis in axes)
+    if total > MAX_GRID_RUNS:
+        raise ValueError(f"the grid has {total} combinations, at most {MAX_GRID_RUNS} are run")
+    return keys, axes, total
+
+def score(summary, rank_by):
+    value = summary[rank_by]
+    if value is None:
+        return float('-inf')
+    return -value if rank_by == 'max_drawdown_percent' else value
+
+def configure(name, params, exits=None):
+    """(strategy, exits) for params that may mix strategy params with sl_percent/tp_percent"""
+    params = dict(params)
+    exits = {'sl_percent': None, 'tp_percent': None, 'allow_short': True, 'costs': None, **(exits or {})}
+    exits.update({key: params.pop(key) for key in EXIT_PARAMS if key in params})
+    return create_strategy(name, params), exits
+
+def grid_pool():
+    """Processes to run grid backtests in, since pure Python backtests don't run side by side in threads.
+    Forked where the platform allows, so strategies registered at runtime (plugins) exist in them too"""
+    method = 'fork' if 'fork' in multiprocessing.get_all_start_methods() else None
+    return ProcessPoolExecutor(GRID_WORKERS, mp_context=multiprocessing.get_context(method))
+
+def grid_chunk(name, candles, exits, keys, combinations):
+    """Summary of a backtest over candles for each combination, None for the ones the strategy refuses"""
+    summaries = []
+    for combination in combinations:
+        try:
+            strategy, run_exits = configure(name, dict(zip(keys, combination)), exits)
+        except ValueError:
+            summaries.append(None)  # Combinations the strategy refuses, fast >= slow and the like
+            continue
+        summaries.append(backtest(strategy, candles, **run_exits)['summary'])
+    return summaries
+
+def grid_search(name, candles, grid, exits=None, rank_by='total_return_percent', holdout=0.3, limit=10,
+                progress=None, pool=None):
+    """Backtest strategy name over every combination of grid ({param: values}, strategy params and
+    sl_percent/tp_percent) and rank the combinations, calling progress(done, total) as chunks of them finish.
+    The backtests run in pool, a grid_pool() of its own when not given.
+
+    Combinations are ranked on the first 1 - holdout of the candles only, then the best limit are run over
+    all of them and judged on the trades entered in the held out rest, which they were never tuned on. Each
+    result carries warnings when it looks overfitted: too few trades, an edge that fades out of sample, or
+    a peak whose neighbours in the grid do much worse.
+    """
+    if rank_by not in RANKINGS:
+        raise ValueError(f"rank_by must be one of {', '.join(RANKINGS)}")
+    if not 0 <= holdout < 1:
+        raise ValueError('holdout must be from 0 up to 1')
+    keys, axes, total = grid_axes(name, grid)
+
+    split = int(len(candles) * (1 - holdout))
+    in_sample = candles[:split]
+
+    def build(combination):
+        return configure(name, dict(zip(keys, combination)), exits)
+
+    def combination(indices):
+        return tuple(axis[i] for axis, i in zip(axes, indices))
+
+    everything = list(itertools.product(*(range(len(axis)) for axis in axes)))
+    size = math.ceil(len(everything) / (GRID_WORKERS * GRID_CHUNKS))
+    chunks = [everything[i:i + size] for i in range(0, len(everything), size)]
+    summaries = [None] * len(chunks)
+    own_pool = pool is None
+    pool = pool or grid_pool()
+    try:
+        # The candles travel to a worker once per chunk rather than once per combination
+        futures = {pool.submit(grid_chunk, name, in_sample, exits, keys, [combination(i) for i in chunk]): n
+                   for n, chunk in enumerate(chunks)}
+        done = 0
+        for future in as_completed(futures):
+            n = futures[future]
+            summaries[n] = future.result()
+            done += len(chunks[n])
+            if progress is not None:
+                progress(done, total)
+    finally:
+        if own_pool:
+            pool.shutdown(cancel_futures=True)
+    # Back in grid order, so ties rank the same however the chunks finished
+    results = [{'indices': indices, 'params': dict(zip(keys, combination(indices))), 'in_sample': summary}
+               for chunk, chunk_summaries in zip(chunks, summaries)
+               for indices, summary in zip(chunk, chunk_summaries) if summary is not None]
+    results.sort(key=lambda r: score(r['in_sample'], rank_by), reverse=True)
+    by_indices = {r['indices']: r for r in results}
+
+    def validate(result):
+        warnings = []
+        trades = result['in_sample']['trades']
+        if trades < MIN_TRADES:
+            warnings.append(f"only {trades} trades in sample, too few to tell skill from luck")
+        if holdout:
+            strategy, run_exits = build(tuple(result['params'][k] for k in keys))
+            later = [t for t in backtest(strategy, candles, **run_exits)['trades']
+                     if split < len(candles) and t['entry_time'] >= candles[split]['time']]
+            result['out_of_sample'] = summarize(later)
+            before = result['in_sample']['average_return_percent']
+            after = result['out_of_sample']['average_return_percent']
+            if before is not None and before > 0 and (after is None or after < before / 2):
+                warnings.append('the average trade out of sample is under half what it was in sample')
+        neighbours = []
+        for axis, i in enumerate(result['indices']):
+            for step in (-1, 1):
+                indices = result['indices'][:axis] + (result['indices'][axis] + step,) + result['indices'][axis + 1:]
+                if indices in by_indices:
+                    neighbours.append(score(by_indices[indices]['in_sample'], rank_by))
+        best = score(result['in_sample'], rank_by)
+        if neighbours and best > 0 and sorted(neighbours)[len(neighbours) // 2] < best / 2:
+            warnings.append('neighbouring parameter values do much worse, the peak may be noise')
+        result['warnings'] = warnings
+        del result['indices']
+        return result
+
+    ranked = [validate(result) for result in results[:limit]]
+    warnings = []
+    if len(results) >= 50:
+        warnings.append(f"the best of {len(results)} combinations looks good partly by chance, "
+                        f"go by the out of sample figures")
+    if not holdout:
+        warnings.append('nothing held out, so no result was checked on candles it was not tuned on')
+
+    def period(part):
+        return {'from': part[0]['time'].isoformat() if part else None,
+                'to': part[-1]['time'].isoformat() if part else None, 'candles': len(part)}
+
+    return {
+        'strategy': name,
+        'rank_by': rank_by,
+        'combinations': total,
+        'tested': len(results),
+        'holdout': holdout,
+        'periods': {'in_sample': period(in_sample), 'out_of_sample': period(candles[split:])},
+        'results': ranked,
+        'warnings': warnings
+    }
+
+def walk_forward_windows(name, grid, candles, in_sample, out_of_sample):
+    """Windows a walk-forward analysis over candles candles would have, raising ValueError when it can't be
+    run or would run more than MAX_WALK_FORWARD_RUNS backtests"""
+    if in_sample < 2 or out_of_sample < 1:
+        raise ValueError('in_sample needs at least 2 candles and out_of_sample at least 1')
+    if candles < in_sample + out_of_sample:
+        raise ValueError(f"walk-forward needs at least {in_sample + out_of_sample} candles, there are {candles}")
+    windows = (candles - in_sample - out_of_sample) // out_of_sample + 1
+    combinations = grid_axes(name, grid)[2]
+    if windows * combinations > MAX_WALK_FORWARD_RUNS:
+        raise ValueError(f"{windows} windows of {combinations} combinations is {windows * combinations} backtests, "
+                         f"at most {MAX_WALK_FORWARD_RUNS} are run: widen out_of_sample or shrink the grid")
+    return windows
+
+def walk_forward(name, candles, grid, exits=None, rank_by='total_return_percent', in_sample=200, out_of_sample=50,
+                 progress=None):
+    """Optimize over in_sample candles, trade the best parameters on the out_of_sample candles after them,
+    roll both windows forward by out_of_sample and repeat to the end of candles, calling progress(done, total)
+    after each window.
+
+    Only the out of sample trades are added up, so the result is what tuning this way would really have
+    earned. efficiency compares the average out of sample trade to the average in sample one: near 1 the
+    edge carries over, well under 1 (or negative) the optimizer is fitting noise.
+    """
+    walk_forward_windows(name, grid, len(candles), in_sample, out_of_sample)
+    windows, trades = [], []
+    in_sample_total = in_sample_trades = 0
+    starts = range(0, len(candles) - in_sample - out_of_sample + 1, out_of_sample)
+    with grid_pool() as pool:
+        for start in starts:
+            tested = start + in_sample
+            end = tested + out_of_sample
+            optimized = grid_search(name, candles[start:tested], grid, exits, rank_by, holdout=0, limit=1,
+                                    pool=pool)
+            if not optimized['results']:
+                raise ValueError('no combination of the grid is valid for the strategy')
+            best = optimized['results'][0]
+            strategy, run_exits = configure(name, best['params'], exits)
+            # Run from the start of the window so indicators are warmed up, keeping the trades entered after it
+            window_trades = [t for t in backtest(strategy, candles[start:end], **run_exits)['trades']
+                             if t['entry_time'] >= candles[tested]['time']]
+            trades.extend(window_trades)
+            if best['in_sample']['trades']:
+                in_sample_total += best['in_sample']['average_return_percent'] * best['in_sample']['trades']
+                in_sample_trades += best['in_sample']['trades']
+            windows.append({
+                'in_sample': {'from': candles[start]['time'].isoformat(),
+                              'to': candles[tested - 1]['time'].isoformat()},
+                'out_of_sample': {'from': candles[tested]['time'].isoformat(),
+                                  'to': candles[end - 1]['time'].isoformat()},
+                'params': best['params'],
+                'in_sample_summary': best['in_sample'],
+                'out_of_sample_summary': summarize(window_trades)
+            })
+            if progress is not None:
+                progress(len(windows), len(starts))
+
+    summary = summarize(trades)
+    before = in_sample_total / in_sample_trades if in_sample_trades else None
+    after = summary['average_return_percent']
+    efficiency = round(after / before, 3) if before and after is not None and before > 0 else None
+    warnings = []
+    if summary['trades'] < MIN_TRADES:
+        warnings.append(f"only {summary['trades']} out of sample trades, too few to judge the strategy by")
+    if efficiency is not None and efficiency < 0.5:
+        warnings.append('the average out of sample trade is under half the in sample one, the optimizer is '
+                        'likely fitting noise')
+    if before is not None and before > 0 and after is not None and after <= 0:
+        warnings.append('profitable in sample but losing out of sample')
+    distinct = len({tuple(sorted(w['params'].items())) for w in windows})
+    if len(windows) > 2 and distinct == len(windows):
+        warnings.append('every window picked different parameters, none of them is a stable optimum')
+    for trade in trades:
+        trade['entry_time'] = trade['entry_time'].isoformat()
+        trade['exit_time'] = trade['exit_time'].isoformat()
+    return {
+        'strategy': name,
+        'rank_by': rank_by,
+        'in_sample': in_sample,
+        'out_of_sample': out_of_sample,
+        'windows': windows,
+        'trades': trades,
+        'summary': summary,
+        'in_sample_average_return_percent': round(before, 3) if before is not None else None,
+        'efficiency': efficiency,
+        'warnings': warnings
+    }
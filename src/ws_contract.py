@@ -0,0 +1,156 @@
+"""Contract check of the dashboard socket messages.
+
+Holds one sample payload, shaped like what the dashboard emits, for every message type in WS_SCHEMAS
+and validates each against its schema. A schema without a sample, or a sample that stopped matching,
+fails the check, so a message can't change shape (or a new one appear) without its schema following.
+
+    python ws_contract.py                              the schemas in btc_alert_dashboard_web.py
+    python ws_contract.py --url http://127.0.0.1:5000  the schemas a running dashboard serves
+
+mock_harness.py runs it against the dashboard it starts.
+"""
+import argparse
+import sys
+
+import requests
+
+T = 1760000000000  # Epoch ms the samples are stamped with
+
+_CANDLE = {'time': T, 'open': 65000.0, 'high': 65120.5, 'low': 64980.0, 'close': 65100.25, 'volume': 12.345}
+_BRACKET = {
+    'id': 'a1b2c3d4', 'type': 'bracket', 'direction': 'LONG', 'quantity': 0.01, 'leverage': 10,
+    'sl_percent': 1.0, 'tp_percent': 2.0,
+    'entry': {'type': 'market', 'price': 65000.0, 'status': 'filled', 'filled_at': T},
+    'sl': {'price': 64350.0, 'status': 'open', 'filled_at': None},
+    'tp': {'price': 66300.0, 'status': 'open', 'filled_at': None},
+    'status': 'open', 'created_at': T
+}
+_DCA_BOT = {
+    'id': 'e5f6a7b8', 'direction': 'LONG', 'mode': 'paper', 'order_size': 100.0, 'size_multiplier': 1.5,
+    'max_orders': 5, 'price_step_percent': 1.0, 'interval_minutes': 0.0, 'take_profit_percent': 1.5, 'repeat': True,
+    'costs': {'maker_fee_bps': 2.0, 'taker_fee_bps': 4.0, 'slippage_bps': 1.0}, 'status': 'running',
+    'orders': [{'price': 65000.0, 'quantity': 0.00153846, 'fee': 0.04, 'time': T}], 'cycles': [],
+    'started_at': '2025-10-09T08:53:20', 'quantity': 0.00153846, 'invested': 100.0, 'average_entry': 65000.0,
+    'take_profit_price': 65975.0, 'unrealized_percent': 0.154, 'realized_pnl': 0.0
+}
+
+SAMPLES = {
+    'status': {'message': 'Connected to Binance'},
+    'error': {'message': 'Error fetching historical data'},
+    'alert': {'message': 'Price reached 70000.00', 'symbol': 'BTCUSDT', 'severity': 'info',
+              'data': {'indicator': 'price', 'value': 70000.0, 'price': 70001.5, 'distance': 0.002},
+              'notes': 'breakout level', 'tags': ['btc']},
+    'play_beep': None,
+    'price_update': {'price': '65100.25'},
+    'price_alert_added': {'id': '1f2e3d4c', 'price': '70000.00', 'notes': 'breakout level'},
+    'sltp_update': {'sl': '64350.00', 'tp': '66300.00'},
+    'backfill_progress': {'timeframe': '1h', 'state': 'done', 'candles': 500, 'completed': 4, 'total': 9},
+    'candle_update': {'topic': 'candles:BTCUSDT:1m', 'timeframe': '1m', 'candle': _CANDLE},
+    'candle_closed': {'topic': 'candles:BTCUSDT:1m', 'timeframe': '1m', 'candle': _CANDLE},
+    'candle_snapshot': {'topic': 'candles:BTCUSDT:1m', 'timeframe': '1m', 'candles': [_CANDLE]},
+    'script_signal': {'script': 'breakout', 'action': 'long', 'message': 'range broken', 'price': 65100.25},
+    'paper_trade': {'run': 'a1b2c3d4', 'strategy': 'ema_cross', 'timeframe': '15m', 'action': 'close',
+                    'direction': 'LONG', 'price': '66300.00', 'time': '2025-10-09 09:00:00', 'reason': 'tp',
+                    'return_percent': 1.96},
+    'equity_update': {'source': 'paper:a1b2c3d4', 'time': T, 'equity': 10196.0, 'peak': 10196.0,
+                      'drawdown_percent': 0.0, 'max_drawdown_percent': 0.8, 'daily_pnl': 196.0,
+                      'daily_pnl_percent': 1.96},
+    'account_balance': {'reason': 'ORDER', 'balances': [{'asset': 'USDT', 'wallet_balance': 1000.0,
+                                                          'cross_wallet_balance': 1000.0, 'balance_change': 0.0}],
+                        'ts': T},
+    'position_update': {'reason': 'ORDER', 'positions': [{'symbol': 'BTCUSDT', 'side': 'BOTH', 'amount': 0.01,
+                                                           'entry_price': 65000.0, 'unrealized_pnl': 1.0,
+                                                           'margin_type': 'cross'}], 'ts': T},
+    'order_update': {'symbol': 'BTCUSDT', 'order_id': 123456789, 'client_order_id': 'web_abc', 'side': 'BUY',
+                     'type': 'MARKET', 'execution': 'TRADE', 'status': 'FILLED', 'price': 0.0, 'quantity': 0.01,
+                     'filled_quantity': 0.01, 'average_price': 65000.0, 'last_fill_price': 65000.0,
+                     'last_fill_quantity': 0.01, 'commission': 0.26, 'commission_asset': 'USDT',
+                     'realized_pnl': 0.0, 'ts': T},
+    'account_update': {'wallet_balance': 1000.0, 'unrealized_pnl': 1.0, 'margin_balance': 1001.0,
+                       'available_balance': 935.0, 'initial_margin': 65.0, 'maintenance_margin': 2.6,
+                       'max_withdraw': 935.0,
+                       'assets': [{'asset': 'USDT', 'wallet_balance': 1000.0, 'margin_balance': 1001.0,
+                                   'available_balance': 935.0}],
+                       'leverage': {'BTCUSDT': 10},
+                       'positions': [{'symbol': 'BTCUSDT', 'side': 'BOTH', 'leverage': 10, 'isolated': False,
+                                      'amount': 0.01, 'entry_price': 65000.0, 'unrealized_pnl': 1.0,
+                                      'initial_margin': 65.0}],
+                       'updated_at': T},
+    'tradingview_signal': {'action': 'long', 'message': 'TradingView long BTCUSDT', 'ticker': 'BTCUSDT',
+                           'price': 65100.25, 'strategy': 'breakout'},
+    'plugin_indicators': {'indicators': {'1m': {'hull_ma': 65050.5}}},
+    'indicators_update': {'indicators': {'1m': {'RSI': '55.20', 'BB': {'upper': '65300.00', 'middle': '65000.00',
+                                                                       'lower': '64700.00'}}}},
+    'screener_update': {'screen': 'c0ffee01', 'name': 'oversold', 'matches': [{'symbol': 'ETHUSDT', 'price': 2400.5}],
+                        'entered': ['ETHUSDT'], 'left': [], 'ts': T},
+    'watchlist_tick': {'watchlist': 'w1', 'ticks': [{'symbol': 'BTCUSDT', 'price': 65100.25, 'change': 1.2,
+                                                     'volume': 1.5e9, 'rsi': None}], 'ts': T},
+    'market_overview': {'symbols': [{'symbol': 'BTCUSDT', 'price': 65100.25, 'change': 1.2, 'high': 65800.0,
+                                     'low': 63400.0, 'volume': 39600.0, 'quote_volume': 2.58e9,
+                                     'funding_rate': 0.0001, 'next_funding_time': T}],
+                        'gainers': ['BTCUSDT'], 'losers': [], 'ts': T},
+    'basis_update': {'symbols': [{'symbol': 'BTCUSDT', 'spot': 65080.0, 'perp': 65100.25, 'basis': 20.25,
+                                  'premium_percent': 0.0311, 'ts': T}]},
+    'liquidation_update': {'symbol': 'BTCUSDT', 'source': 'position', 'position_type': 'LONG', 'entry_price': 65000.0,
+                           'leverage': 10, 'liquidation_price': 58760.0, 'mark_price': 65100.25,
+                           'distance_percent': 9.739},
+    'positioning_update': {'symbol': 'BTCUSDT', 'period': '5m',
+                           'long_short': {'time': T, 'ratio': 1.9, 'long_percent': 65.5, 'short_percent': 34.5},
+                           'taker': {'time': T, 'ratio': 0.95, 'buy_volume': 120.5, 'sell_volume': 126.8}},
+    'session_break': {'symbol': 'BTCUSDT', 'session': 'asia', 'side': 'high', 'level': 65050.0, 'price': 65100.25,
+                      'ts': T},
+    'levels_update': {'symbol': 'BTCUSDT',
+                      'pivots': {'date': '2025-10-08', 'classic': {'P': 64800.0, 'R1': 65400.0, 'S1': 64200.0},
+                                 'camarilla': {'H3': 65200.0, 'L3': 64400.0}},
+                      'fibonacci': {'timeframe': '4h', 'direction': 'up', 'high': 66000.0, 'low': 62000.0,
+                                    'high_time': T, 'low_time': T - 86_400_000,
+                                    'levels': {'0.382': 64472.0, '0.618': 63528.0}}},
+    'sr_zones_update': {'symbol': 'BTCUSDT', 'timeframe': '1h',
+                        'zones': [{'low': 64900.0, 'high': 65000.0, 'kind': 'support', 'touches': 4, 'score': 5.5,
+                                   'last_touch': T}]},
+    'pattern_detected': {'symbol': 'BTCUSDT', 'timeframe': '15m', 'pattern': 'hammer', 'bias': 'bullish', 'time': T,
+                         'close': 65100.25},
+    'divergence_detected': {'symbol': 'BTCUSDT', 'timeframe': '1h', 'kind': 'regular', 'direction': 'bullish',
+                            'oscillators': ['RSI'], 'confidence': 0.7,
+                            'from': {'time': T - 3_600_000 * 12, 'price': 64000.0, 'rsi': 28.0},
+                            'to': {'time': T, 'price': 63800.0, 'rsi': 33.5}},
+    'confluence_update': {'score': 40.0, 'timeframes': {'1h': {'score': 50.0, 'votes': {'RSI': 1, 'MACD': 0}}}},
+    'trade_signal': {'symbol': 'BTCUSDT', 'timeframe': '1h', 'time': T, 'price': 65100.25, 'signal': 'BUY',
+                     'previous': 'NEUTRAL', 'score': 0.6, 'threshold': 0.5,
+                     'conditions': [{'condition': 'rsi_oversold', 'vote': 1, 'weight': 1.0, 'contribution': 0.6,
+                                     'detail': 'RSI 28.0 below 30'}]},
+    'model_signal': {'symbol': 'BTCUSDT', 'timeframe': '1h', 'time': T, 'price': 65100.25, 'signal': 'SELL',
+                     'confidence': 0.72, 'details': {'model': 'v3'}, 'latency_ms': 38},
+    'bracket_update': {'event': 'entry_filled', 'bracket': _BRACKET},
+    'dca_update': {'event': 'order_filled', 'bot': _DCA_BOT},
+    'squeeze': {'symbol': 'BTCUSDT', 'timeframe': '1h', 'state': 'fired', 'time': T, 'started': T - 3_600_000 * 6,
+                'bollinger_width': 1.2, 'keltner_width': 1.5, 'direction': 'bullish'}
+}
+
+def check(schemas, validate):
+    """Failures, one line each, of SAMPLES against schemas with validate(value, schema) -> errors"""
+    failures = [f"{event}: no sample payload" for event in sorted(schemas) if event not in SAMPLES]
+    failures += [f"{event}: sample has no schema" for event in sorted(SAMPLES) if event not in schemas]
+    for event in sorted(set(schemas) & set(SAMPLES)):
+        failures += [f"{event}: {error}" for error in validate(SAMPLES[event], schemas[event])]
+    return failures
+
+def served_schemas(url):
+    response = requests.get(f"{url}/api/v1/schemas", timeout=10)
+    response.raise_for_status()
+    return response.json()['data']['messages']
+
+def main():
+    parser = argparse.ArgumentParser(description='Validate a sample of every socket message against its schema')
+    parser.add_argument('--url', help='check the schemas served by the dashboard at this URL instead')
+    args = parser.parse_args()
+
+    from btc_alert_dashboard_web import WS_SCHEMAS, schema_errors
+    failures = check(served_schemas(args.url) if args.url else WS_SCHEMAS, schema_errors)
+    for failure in failures:
+        print(f"FAIL  {failure}")
+    print(f"{len(SAMPLES)} samples checked, {len(failures)} failures")
+    sys.exit(1 if failures else 0)
+
+if __name__ == '__main__':
+    main()